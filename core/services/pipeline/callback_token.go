@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// CallbackToken authorizes an out-of-process custom task handler to
+// resume exactly one suspended TaskRun. It's handed to the handler in
+// CustomTaskHandler.Execute and must be presented back, unmodified, to
+// the HTTP webhook or gRPC Resume RPC.
+type CallbackToken struct {
+	TaskRunID uuid.UUID `json:"taskRunId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Sign serializes the token and appends an HMAC-SHA256 signature keyed
+// on secret, base64url-encoding the result so it's safe to pass around
+// as a bearer string.
+func (t CallbackToken) Sign(secret []byte) (string, error) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	sig := mac.Sum(nil)
+
+	out := struct {
+		Payload   []byte `json:"payload"`
+		Signature []byte `json:"signature"`
+	}{payload, sig}
+	encoded, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+// VerifyCallbackToken checks the signature on a token produced by
+// CallbackToken.Sign and that it hasn't expired, returning the TaskRunID
+// it authorizes.
+func VerifyCallbackToken(token string, secret []byte) (uuid.UUID, error) {
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("callback token: malformed encoding: %w", err)
+	}
+
+	var wrapper struct {
+		Payload   []byte `json:"payload"`
+		Signature []byte `json:"signature"`
+	}
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		return uuid.UUID{}, fmt.Errorf("callback token: malformed payload: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(wrapper.Payload)
+	expected := mac.Sum(nil)
+	if subtle.ConstantTimeCompare(expected, wrapper.Signature) != 1 {
+		return uuid.UUID{}, fmt.Errorf("callback token: invalid signature")
+	}
+
+	var t CallbackToken
+	if err := json.Unmarshal(wrapper.Payload, &t); err != nil {
+		return uuid.UUID{}, fmt.Errorf("callback token: malformed token: %w", err)
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return uuid.UUID{}, fmt.Errorf("callback token: expired at %s", t.ExpiresAt)
+	}
+	return t.TaskRunID, nil
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// defaultCallbackTimeout bounds how long a CustomTask's CallbackToken
+// remains valid when its CallbackTimeout is zero.
+const defaultCallbackTimeout = 1 * time.Hour
+
+// CustomTaskHandler is implemented by an out-of-process node type
+// (Keeper, VRF, OCR2, or a third party's own runner) and registered
+// against a ref under a CustomTaskRegistry. Execute is expected to
+// return quickly, after handing the work off asynchronously; token is
+// the CallbackToken.Sign output, a bearer string the handler hands back
+// unmodified once its work is done. The run resumes later when it calls
+// back with that token via the HTTP webhook (see NewWebhookHandler) or
+// the gRPC Resume RPC (see RegisterGRPCServer), both of which end up
+// calling ORM.UpdateTaskRun, or ORM.FailTaskRun when it reports an error.
+type CustomTaskHandler interface {
+	Execute(ctx context.Context, spec string, vars []Result, token string) error
+}
+
+// CustomTaskRegistry maps a CustomTask's `ref` attribute to the handler
+// that should execute it. Node operators populate it at startup with
+// whatever custom task types they've enabled.
+type CustomTaskRegistry interface {
+	Register(ref string, handler CustomTaskHandler)
+	Lookup(ref string) (CustomTaskHandler, bool)
+}
+
+type customTaskRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]CustomTaskHandler
+}
+
+// NewCustomTaskRegistry returns an empty, concurrency-safe
+// CustomTaskRegistry.
+func NewCustomTaskRegistry() CustomTaskRegistry {
+	return &customTaskRegistry{handlers: make(map[string]CustomTaskHandler)}
+}
+
+func (r *customTaskRegistry) Register(ref string, handler CustomTaskHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[ref] = handler
+}
+
+func (r *customTaskRegistry) Lookup(ref string) (CustomTaskHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[ref]
+	return h, ok
+}
+
+// CustomTask dispatches execution to an out-of-process handler chosen by
+// Ref, generalizing the suspend/resume pattern already used by async
+// BridgeTasks into a stable extension point: third parties register a
+// CustomTaskHandler instead of forking core to add a new node type.
+type CustomTask struct {
+	BaseTask
+
+	Ref  string
+	Spec string
+
+	Registry        CustomTaskRegistry
+	CallbackSecret  []byte
+	CallbackTimeout time.Duration
+}
+
+var _ Task = (*CustomTask)(nil)
+
+func (t *CustomTask) Type() TaskType { return TaskTypeCustom }
+
+func (t *CustomTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	if t.Registry == nil {
+		return Result{Error: fmt.Errorf("custom task %q: no CustomTaskRegistry configured", t.dotID)}
+	}
+	handler, ok := t.Registry.Lookup(t.Ref)
+	if !ok {
+		return Result{Error: fmt.Errorf("custom task %q: no handler registered for ref %q", t.dotID, t.Ref)}
+	}
+
+	timeout := t.CallbackTimeout
+	if timeout == 0 {
+		timeout = defaultCallbackTimeout
+	}
+	token, err := CallbackToken{TaskRunID: taskRunID, ExpiresAt: time.Now().Add(timeout)}.Sign(t.CallbackSecret)
+	if err != nil {
+		return Result{Error: fmt.Errorf("custom task %q: signing callback token: %w", t.dotID, err)}
+	}
+
+	if err := handler.Execute(ctx, t.Spec, vars, token); err != nil {
+		return Result{Error: fmt.Errorf("custom task %q: %w", t.dotID, err)}
+	}
+	return Result{Error: ErrTaskSuspended}
+}
+
+// BindCustomTaskRegistry wires registry and secret into every CustomTask
+// in the pipeline. Parse can't do this itself since the registry and
+// signing secret are runtime configuration, not part of the DOT spec.
+func (p *Pipeline) BindCustomTaskRegistry(registry CustomTaskRegistry, secret []byte) {
+	for _, task := range p.Tasks {
+		if ct, ok := task.(*CustomTask); ok {
+			ct.Registry = registry
+			ct.CallbackSecret = secret
+		}
+	}
+}
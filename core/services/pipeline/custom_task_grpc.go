@@ -0,0 +1,56 @@
+package pipeline
+
+//go:generate protoc --go_out=. --go_opt=module=github.com/smartcontractkit/chainlink/core/services/pipeline --go-grpc_out=. --go-grpc_opt=module=github.com/smartcontractkit/chainlink/core/services/pipeline custom_task.proto
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/smartcontractkit/chainlink/core/services/pipeline/pb"
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+	"google.golang.org/grpc"
+)
+
+// GRPCCustomTaskServer implements pb.CustomTaskServiceServer, the gRPC
+// counterpart to NewWebhookHandler: an out-of-process CustomTaskHandler
+// that would rather speak gRPC than POST JSON calls Resume to hand back
+// its result and wake the suspended run.
+type GRPCCustomTaskServer struct {
+	pb.UnimplementedCustomTaskServiceServer
+
+	orm    ORM
+	ds     sqlutil.DataStore
+	secret []byte
+}
+
+// NewGRPCCustomTaskServer returns a server ready to be registered on a
+// *grpc.Server via RegisterGRPCServer.
+func NewGRPCCustomTaskServer(orm ORM, ds sqlutil.DataStore, secret []byte) *GRPCCustomTaskServer {
+	return &GRPCCustomTaskServer{orm: orm, ds: ds, secret: secret}
+}
+
+// RegisterGRPCServer mounts the CustomTaskService onto srv.
+func RegisterGRPCServer(srv *grpc.Server, s *GRPCCustomTaskServer) {
+	pb.RegisterCustomTaskServiceServer(srv, s)
+}
+
+func (s *GRPCCustomTaskServer) Resume(ctx context.Context, req *pb.ResumeRequest) (*pb.ResumeResponse, error) {
+	taskRunID, err := VerifyCallbackToken(req.Token, s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Error != "" {
+		_, _, err = s.orm.FailTaskRun(ctx, s.ds, taskRunID, req.Error)
+		return &pb.ResumeResponse{}, err
+	}
+
+	var value interface{}
+	if len(req.ValueJson) > 0 {
+		if err := json.Unmarshal(req.ValueJson, &value); err != nil {
+			return nil, err
+		}
+	}
+	_, _, err = s.orm.UpdateTaskRun(ctx, s.ds, taskRunID, value)
+	return &pb.ResumeResponse{}, err
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+)
+
+// WebhookResumeRequest is the JSON body a CustomTaskHandler POSTs back to
+// NewWebhookHandler once its out-of-process work has finished.
+type WebhookResumeRequest struct {
+	// Token is the CallbackToken.Sign output handed to the handler in
+	// CustomTaskHandler.Execute.
+	Token string `json:"token"`
+	// Value is the task's result, passed through to downstream tasks.
+	// Omit it and set Error instead to report a failure.
+	Value interface{} `json:"value,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// NewWebhookHandler returns an http.Handler that custom task handlers
+// call back into to resume a suspended run. It verifies the request's
+// token against secret before touching the database, so a forged or
+// stale callback can't resume (or spoof) someone else's task run.
+func NewWebhookHandler(orm ORM, ds sqlutil.DataStore, secret []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req WebhookResumeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "malformed request body", http.StatusBadRequest)
+			return
+		}
+
+		taskRunID, err := VerifyCallbackToken(req.Token, secret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		if req.Error != "" {
+			if _, _, err := orm.FailTaskRun(ctx, ds, taskRunID, req.Error); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else if _, _, err := orm.UpdateTaskRun(ctx, ds, taskRunID, req.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,18 @@
+package pipeline
+
+import "errors"
+
+// ErrNotImplemented is returned by task types whose execution depends on
+// infrastructure (HTTP clients, bridge registries, ...) that isn't wired
+// up in a given context.
+var ErrNotImplemented = errors.New("pipeline: not implemented")
+
+// ErrNoSuchTask is returned when a DOT id doesn't resolve to any task in
+// a Pipeline.
+var ErrNoSuchTask = errors.New("pipeline: no such task")
+
+// ErrTaskSuspended is returned by Task.Run to indicate that the task has
+// dispatched its work to an out-of-process handler and will resume later
+// via ORM.UpdateTaskRun, rather than failed. The runner treats it as
+// neither a success nor a retryable error.
+var ErrTaskSuspended = errors.New("pipeline: task suspended awaiting external resume")
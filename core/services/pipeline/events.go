@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/smartcontractkit/chainlink/core/logger"
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+)
+
+// RunEventType identifies what changed about a Run or TaskRun in a
+// RunEvent.
+type RunEventType string
+
+const (
+	RunEventRunCreated   RunEventType = "run_created"
+	RunEventTaskStarted  RunEventType = "task_started"
+	RunEventTaskFinished RunEventType = "task_finished"
+	RunEventRunSuspended RunEventType = "run_suspended"
+	RunEventRunResumed   RunEventType = "run_resumed"
+	RunEventRunCompleted RunEventType = "run_completed"
+)
+
+// RunEvent is the payload ORM mutations publish to a RunEventBus. DotID
+// is only set for the two task-level event types.
+type RunEvent struct {
+	Type  RunEventType `json:"type"`
+	RunID int64        `json:"runID"`
+	DotID string       `json:"dotID,omitempty"`
+	State RunStatus    `json:"state,omitempty"`
+}
+
+// RunEventFilter narrows a Subscribe call to events for a single run. The
+// zero value matches every run.
+type RunEventFilter struct {
+	RunID int64
+}
+
+func (f RunEventFilter) matches(e RunEvent) bool {
+	return f.RunID == 0 || f.RunID == e.RunID
+}
+
+// RunEventBus publishes the RunEvents a Runner/ORM produce as they
+// create, suspend, resume, and complete Runs, so external schedulers can
+// drive dashboards or trigger dependent jobs by subscribing instead of
+// polling ORM.FindRun.
+type RunEventBus interface {
+	Publish(ctx context.Context, event RunEvent) error
+	// Subscribe returns a channel of events matching filter. The channel
+	// is closed when ctx is done; callers must not block on sending to
+	// it themselves, as the bus drops events to slow subscribers rather
+	// than stalling the publisher.
+	Subscribe(ctx context.Context, filter RunEventFilter) (<-chan RunEvent, error)
+}
+
+// noopRunEventBus is the default used by NewORM when no RunEventBus is
+// configured via WithEventBus, so orm's publish calls don't need nil
+// checks.
+type noopRunEventBus struct{}
+
+func (noopRunEventBus) Publish(context.Context, RunEvent) error { return nil }
+
+func (noopRunEventBus) Subscribe(context.Context, RunEventFilter) (<-chan RunEvent, error) {
+	ch := make(chan RunEvent)
+	close(ch)
+	return ch, nil
+}
+
+const (
+	runEventsChannel       = "pipeline_run_events"
+	runEventsSubChanBuffer = 64
+	minReconnectInterval   = 1 * time.Second
+	maxReconnectInterval   = 1 * time.Minute
+)
+
+// postgresRunEventBus publishes RunEvents via `pg_notify` on
+// runEventsChannel and fans incoming notifications back out to
+// Subscribe's callers via pq.Listener, so every node process observes
+// the same stream regardless of which process's ORM call produced it.
+type postgresRunEventBus struct {
+	ds  sqlutil.DataStore
+	uri string
+
+	mu       sync.Mutex
+	listener *pq.Listener
+	subs     map[chan RunEvent]RunEventFilter
+}
+
+// NewPostgresRunEventBus returns a RunEventBus that publishes through ds
+// and, once Subscribe is first called, opens a dedicated LISTEN
+// connection to dbURI to receive notifications from any process
+// (including this one).
+func NewPostgresRunEventBus(ds sqlutil.DataStore, dbURI string) RunEventBus {
+	return &postgresRunEventBus{ds: ds, uri: dbURI, subs: make(map[chan RunEvent]RunEventFilter)}
+}
+
+func (b *postgresRunEventBus) Publish(ctx context.Context, event RunEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = b.ds.ExecContext(ctx, `SELECT pg_notify($1, $2)`, runEventsChannel, string(payload))
+	return err
+}
+
+func (b *postgresRunEventBus) Subscribe(ctx context.Context, filter RunEventFilter) (<-chan RunEvent, error) {
+	if err := b.ensureListening(); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan RunEvent, runEventsSubChanBuffer)
+	b.mu.Lock()
+	b.subs[ch] = filter
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (b *postgresRunEventBus) ensureListening() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.listener != nil {
+		return nil
+	}
+
+	listener := pq.NewListener(b.uri, minReconnectInterval, maxReconnectInterval, b.reportProblem)
+	if err := listener.Listen(runEventsChannel); err != nil {
+		return err
+	}
+	b.listener = listener
+	go b.broadcastLoop()
+	return nil
+}
+
+func (b *postgresRunEventBus) broadcastLoop() {
+	for n := range b.listener.Notify {
+		if n == nil {
+			// nil notifications signal a dropped/reconnected
+			// connection; subscribers just miss whatever NOTIFYs
+			// happened in between rather than getting a stale one.
+			continue
+		}
+		var event RunEvent
+		if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+			logger.Errorw("pipeline: could not unmarshal run event notification", "err", err)
+			continue
+		}
+
+		b.mu.Lock()
+		for ch, filter := range b.subs {
+			if !filter.matches(event) {
+				continue
+			}
+			select {
+			case ch <- event:
+			default:
+				logger.Errorw("pipeline: dropping run event, subscriber is not keeping up", "event", event)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *postgresRunEventBus) reportProblem(_ pq.ListenerEventType, err error) {
+	if err != nil {
+		logger.Errorw("pipeline: run event listener error", "err", err)
+	}
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/smartcontractkit/chainlink/core/logger"
+)
+
+var runEventsUpgrader = websocket.Upgrader{
+	// Run events carry no secrets and the endpoint is authenticated the
+	// same way as the rest of the admin API, so any origin is fine here.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewRunEventsWebsocketHandler returns an http.Handler that upgrades to a
+// WebSocket and streams bus's RunEvents as newline-delimited JSON for as
+// long as the client stays connected. An optional `runID` query
+// parameter restricts the stream to a single run; this is the same
+// Subscribe(ctx, filter) API a GraphQL subscription resolver would call
+// directly in-process instead of going over the wire.
+func NewRunEventsWebsocketHandler(bus RunEventBus) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var filter RunEventFilter
+		if s := r.URL.Query().Get("runID"); s != "" {
+			runID, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid runID", http.StatusBadRequest)
+				return
+			}
+			filter.RunID = runID
+		}
+
+		conn, err := runEventsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Errorw("pipeline: could not upgrade run events websocket", "err", err)
+			return
+		}
+		defer conn.Close()
+
+		ctx := r.Context()
+		events, err := bus.Subscribe(ctx, filter)
+		if err != nil {
+			logger.Errorw("pipeline: could not subscribe to run events", "err", err)
+			return
+		}
+
+		for event := range events {
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package pipeline
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/smartcontractkit/chainlink/core/store/models"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Spec is the persisted representation of a Pipeline: its DOT source plus
+// any execution-wide settings that apply to every Run derived from it.
+type Spec struct {
+	ID              int32
+	DotDagSource    string
+	CreatedAt       time.Time
+	MaxTaskDuration models.Interval
+
+	// AllowPartialResults, when set, lets a Run that had one or more
+	// failed tasks still complete as RunStatusPartial and expose the
+	// outputs of whichever subgraph succeeded, rather than erroring the
+	// whole run. Individual tasks can opt into the same behavior with
+	// the skipOnFail DOT attribute regardless of this setting.
+	AllowPartialResults bool
+}
+
+// RunStatus describes where a Run currently sits in its lifecycle.
+type RunStatus string
+
+const (
+	RunStatusUnknown   RunStatus = ""
+	RunStatusRunning   RunStatus = "running"
+	RunStatusSuspended RunStatus = "suspended"
+	RunStatusErrored   RunStatus = "errored"
+	RunStatusCompleted RunStatus = "completed"
+	// RunStatusPartial is terminal like Completed/Errored, but marks a
+	// run where at least one task failed or was skipped while at least
+	// one other task still produced a usable output.
+	RunStatusPartial RunStatus = "partial"
+)
+
+// Finished returns true if this status is a terminal one that will no
+// longer transition.
+func (rs RunStatus) Finished() bool {
+	return rs == RunStatusErrored || rs == RunStatusCompleted || rs == RunStatusPartial
+}
+
+// Run is one execution of a Spec's DAG. It owns the collection of
+// TaskRuns produced as the DAG is walked.
+type Run struct {
+	ID               int64
+	PipelineSpecID   int32
+	PipelineSpec     Spec
+	Errors           RunErrors
+	Inputs           JSONSerializable
+	Outputs          JSONSerializable
+	CreatedAt        time.Time
+	FinishedAt       *time.Time
+	PipelineTaskRuns []TaskRun
+	State            RunStatus
+
+	// FinallyStartedAt is set the moment the run's finally tasks (if
+	// any) begin executing. A non-nil FinallyStartedAt with a non-nil
+	// FinishedAt means the finally block ran to completion; a nil
+	// FinishedAt with a non-nil FinallyStartedAt means it's still in
+	// progress, and the run must not yet be treated as terminal.
+	FinallyStartedAt *time.Time
+
+	// AllowPartialResults mirrors the owning Spec's flag for the
+	// duration of a single Run. ExecuteAndSave populates it from the
+	// Pipeline before execution; it isn't itself a pipeline_runs column,
+	// it just gives classifyRunStatus enough context to tell a run with
+	// a genuine task error that should still surface its other
+	// successful subtrees (RunStatusPartial) from one that shouldn't
+	// (RunStatusErrored).
+	AllowPartialResults bool
+}
+
+// ByDotID returns the TaskRun with the given DOT id, or nil if the run
+// doesn't have one (e.g. it hasn't been loaded yet).
+func (r Run) ByDotID(id string) *TaskRun {
+	for i := range r.PipelineTaskRuns {
+		if r.PipelineTaskRuns[i].DotID == id {
+			return &r.PipelineTaskRuns[i]
+		}
+	}
+	return nil
+}
+
+// TaskType identifies which Task implementation a TaskRun corresponds to.
+type TaskType string
+
+const (
+	TaskTypeHTTP      TaskType = "http"
+	TaskTypeBridge    TaskType = "bridge"
+	TaskTypeMedian    TaskType = "median"
+	TaskTypeJSONParse TaskType = "jsonparse"
+	TaskTypeMultiply  TaskType = "multiply"
+	TaskTypeCustom    TaskType = "custom"
+)
+
+// TaskRun is the persisted record of a single task's execution within a
+// Run. Rows are inserted either pending (Output/FinishedAt unset) or
+// already finished; a pending row is updated in place once the task
+// completes or is resumed from suspension.
+type TaskRun struct {
+	ID            int64
+	Type          TaskType
+	PipelineRun   Run
+	PipelineRunID int64
+	Output        *JSONSerializable
+	Error         null.String
+	CreatedAt     time.Time
+	FinishedAt    null.Time
+	Index         int32
+	DotID         string
+	TaskRunID     uuid.UUID
+
+	// Attempts counts how many retries of this task have been started:
+	// zero on the first execution, and one more for each RetryTaskRun.
+	// It only advances past 0 for tasks whose spec attribute enables
+	// retries.
+	Attempts uint32
+
+	// Skipped marks a task whose failure was recorded as a skip rather
+	// than an error, because it has skipOnFail set or one of its inputs
+	// was itself skipped or errored. It's distinct from a genuine Error:
+	// a skipped task doesn't fail the run on its own.
+	Skipped bool
+}
+
+// JSONSerializable is a wrapper that allows a Go value to be stored as
+// JSONB and to distinguish an explicit SQL NULL from the JSON null
+// literal.
+type JSONSerializable struct {
+	Val  interface{}
+	Null bool
+}
+
+func (js *JSONSerializable) UnmarshalJSON(bs []byte) error {
+	if js == nil {
+		*js = JSONSerializable{}
+	}
+	if string(bs) == "null" {
+		js.Null = true
+		return nil
+	}
+	return json.Unmarshal(bs, &js.Val)
+}
+
+func (js JSONSerializable) MarshalJSON() ([]byte, error) {
+	if js.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(js.Val)
+}
+
+func (js *JSONSerializable) Scan(value interface{}) error {
+	if value == nil {
+		*js = JSONSerializable{Null: true}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("JSONSerializable#Scan received a value of type %T", value)
+	}
+	return js.UnmarshalJSON(bytes)
+}
+
+func (js JSONSerializable) Value() (driver.Value, error) {
+	if js.Null {
+		return nil, nil
+	}
+	return js.MarshalJSON()
+}
+
+// RunErrors is the ordered set of error strings produced by a Run's
+// tasks, one slot per task (nullable so that successful tasks leave a
+// gap rather than shifting indices).
+type RunErrors []null.String
+
+func (re RunErrors) Value() (driver.Value, error) {
+	return json.Marshal(re)
+}
+
+func (re *RunErrors) Scan(value interface{}) error {
+	if value == nil {
+		*re = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("RunErrors#Scan received a value of type %T", value)
+	}
+	return json.Unmarshal(bytes, re)
+}
+
+// HasErrors returns true if any task in the run recorded an error.
+func (re RunErrors) HasErrors() bool {
+	for _, e := range re {
+		if e.Valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,379 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/smartcontractkit/chainlink/core/store/models"
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+)
+
+// Config is the subset of the node's general configuration the ORM
+// needs. It's satisfied by *store.Config; accepting it as an interface
+// keeps this package from depending on the store package.
+type Config interface {
+	DatabaseMaximumTxDuration() time.Duration
+}
+
+// ORM persists Pipeline Specs and Runs. Every method takes a
+// sqlutil.DataStore so callers can compose ORM calls inside their own
+// transaction (passing a *sqlx.Tx) or let the ORM open its own (passing
+// the *sqlx.DB it was constructed with); either way ctx cancellation
+// propagates straight into the query. Implementations must be safe for
+// concurrent use.
+type ORM interface {
+	CreateSpec(ctx context.Context, ds sqlutil.DataStore, pipeline Pipeline, maxTaskDuration models.Interval) (int32, error)
+	CreateRun(ctx context.Context, ds sqlutil.DataStore, run *Run) error
+	StoreRun(ctx context.Context, ds sqlutil.DataStore, run *Run) (restart bool, err error)
+	FindRun(ctx context.Context, ds sqlutil.DataStore, id int64) (Run, error)
+	UpdateTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, result interface{}) (run Run, start bool, err error)
+	FailTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, taskErr string) (run Run, start bool, err error)
+	RetryTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID) (run Run, start bool, err error)
+}
+
+type orm struct {
+	ds     sqlutil.DataStore
+	config Config
+	events RunEventBus
+}
+
+// NewORM returns an ORM. Every method runs against the DataStore passed
+// to that call; ds and the optional config are kept on the returned ORM
+// but no method reads them, so no transaction timeout is applied. The
+// returned ORM publishes no RunEvents until wrapped with WithEventBus.
+func NewORM(ds sqlutil.DataStore, config ...Config) ORM {
+	o := &orm{ds: ds, events: noopRunEventBus{}}
+	if len(config) > 0 {
+		o.config = config[0]
+	}
+	return o
+}
+
+// WithEventBus returns a copy of o that publishes RunEvents to bus as it
+// creates, suspends, resumes, and completes Runs. It's a separate step
+// from NewORM so callers that don't care about the event stream (most
+// tests) don't need to construct or stub one. An o that wasn't returned
+// by NewORM is returned unchanged, without bus attached.
+func WithEventBus(o ORM, bus RunEventBus) ORM {
+	conc, ok := o.(*orm)
+	if !ok {
+		return o
+	}
+	cp := *conc
+	cp.events = bus
+	return &cp
+}
+
+// CreateSpec persists a parsed Pipeline as a Spec, returning its ID.
+func (o *orm) CreateSpec(ctx context.Context, ds sqlutil.DataStore, pipeline Pipeline, maxTaskDuration models.Interval) (int32, error) {
+	spec := Spec{
+		DotDagSource:        pipeline.Source,
+		MaxTaskDuration:     maxTaskDuration,
+		AllowPartialResults: pipeline.AllowPartialResults,
+	}
+
+	var id int32
+	err := ds.GetContext(ctx, &id, `
+		INSERT INTO pipeline_specs (dot_dag_source, max_task_duration, allow_partial_results, created_at)
+		VALUES ($1, $2, $3, now())
+		RETURNING id
+	`, spec.DotDagSource, spec.MaxTaskDuration, spec.AllowPartialResults)
+	spec.ID = id
+	return spec.ID, err
+}
+
+// CreateRun inserts run, which must not yet have any TaskRuns attached;
+// StoreRun is responsible for persisting those as the DAG executes.
+func (o *orm) CreateRun(ctx context.Context, ds sqlutil.DataStore, run *Run) error {
+	rows, err := ds.NamedQueryContext(ctx, `
+		INSERT INTO pipeline_runs (pipeline_spec_id, state, inputs, outputs, errors, created_at, finished_at)
+		VALUES (:pipeline_spec_id, :state, :inputs, :outputs, :errors, :created_at, :finished_at)
+		RETURNING id
+	`, run)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&run.ID); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return o.events.Publish(ctx, RunEvent{Type: RunEventRunCreated, RunID: run.ID, State: run.State})
+}
+
+// FindRun loads a Run and its TaskRuns by ID.
+func (o *orm) FindRun(ctx context.Context, ds sqlutil.DataStore, id int64) (Run, error) {
+	var run Run
+	if err := ds.GetContext(ctx, &run, `SELECT * FROM pipeline_runs WHERE id = $1`, id); err != nil {
+		return Run{}, err
+	}
+	if err := ds.SelectContext(ctx, &run.PipelineTaskRuns, `
+		SELECT * FROM pipeline_task_runs WHERE pipeline_run_id = $1 ORDER BY id ASC
+	`, run.ID); err != nil {
+		return Run{}, err
+	}
+	return run, nil
+}
+
+// StoreRun upserts the task runs attached to run, and advances run.State
+// based on whether the DAG is now complete, errored, or still waiting on
+// an async task to resume it.
+//
+// Each task run is upserted with a single INSERT ... ON CONFLICT DO
+// UPDATE ... RETURNING, which takes Postgres's own row lock instead of
+// the previous SELECT ... FOR UPDATE followed by a separate UPDATE; the
+// WHERE clause on the DO UPDATE makes sure we never clobber a row
+// another process (e.g. an async bridge callback) already finished. If
+// that happens the RETURNING clause yields no row, we fetch what's
+// actually there, and StoreRun reports restart=true so the caller
+// re-evaluates the DAG immediately instead of suspending.
+func (o *orm) StoreRun(ctx context.Context, ds sqlutil.DataStore, run *Run) (bool, error) {
+	restart := false
+	var taskEvents []RunEvent
+
+	err := sqlutil.Transact(ctx, ds, func(tx sqlutil.DataStore) error {
+		for i := range run.PipelineTaskRuns {
+			tr := &run.PipelineTaskRuns[i]
+
+			// tr reflects the caller's in-memory view, which the upsert
+			// below is about to overwrite via RETURNING, so the only way
+			// to know whether this row was finished before this call is
+			// to ask the database directly; a missing row (not yet
+			// inserted) counts as not finished.
+			var wasFinished bool
+			switch err := tx.GetContext(ctx, &wasFinished, `
+				SELECT finished_at IS NOT NULL FROM pipeline_task_runs WHERE task_run_id = $1
+			`, tr.TaskRunID); err {
+			case nil, sql.ErrNoRows:
+			default:
+				return err
+			}
+
+			rows, err := tx.NamedQueryContext(ctx, `
+				INSERT INTO pipeline_task_runs
+					(pipeline_run_id, task_run_id, type, index, output, error, dot_id, created_at, finished_at, attempts, skipped)
+				VALUES
+					(:pipeline_run_id, :task_run_id, :type, :index, :output, :error, :dot_id, :created_at, :finished_at, :attempts, :skipped)
+				ON CONFLICT (task_run_id) DO UPDATE SET
+					output = EXCLUDED.output,
+					error = EXCLUDED.error,
+					finished_at = EXCLUDED.finished_at,
+					attempts = EXCLUDED.attempts,
+					skipped = EXCLUDED.skipped
+				WHERE pipeline_task_runs.finished_at IS NULL
+				RETURNING *
+			`, tr)
+			if err != nil {
+				return err
+			}
+			gotRow := rows.Next()
+			if gotRow {
+				err = rows.StructScan(tr)
+			}
+			rows.Close()
+			if err != nil {
+				return err
+			}
+			if gotRow {
+				switch {
+				case tr.FinishedAt.Valid && !wasFinished:
+					taskEvents = append(taskEvents, RunEvent{Type: RunEventTaskFinished, RunID: run.ID, DotID: tr.DotID})
+				case !tr.FinishedAt.Valid && !wasFinished:
+					taskEvents = append(taskEvents, RunEvent{Type: RunEventTaskStarted, RunID: run.ID, DotID: tr.DotID})
+				}
+				continue
+			}
+
+			// The WHERE clause excluded our write: someone else already
+			// finished this task. Take their result and restart.
+			var existing TaskRun
+			if err := tx.GetContext(ctx, &existing, `
+				SELECT * FROM pipeline_task_runs WHERE task_run_id = $1
+			`, tr.TaskRunID); err != nil {
+				return err
+			}
+			*tr = existing
+			restart = true
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if restart {
+		// Rows processed earlier in this same call may already have
+		// queued up TaskFinished/TaskStarted events; a later row hitting
+		// the restart branch must not make those vanish. RunSuspended/
+		// RunCompleted never apply here since run.State is forced to
+		// RunStatusRunning below.
+		for _, event := range taskEvents {
+			if err := o.events.Publish(ctx, event); err != nil {
+				return false, err
+			}
+		}
+		run.State = RunStatusRunning
+		return true, nil
+	}
+
+	switch {
+	case run.FinallyStartedAt != nil && run.FinishedAt == nil:
+		// Every main-DAG task is finished, but the finally block is
+		// still queued or in flight: per the invariant on
+		// Run.FinallyStartedAt, the run isn't terminal yet no matter
+		// how the main DAG came out.
+		run.State = RunStatusRunning
+	case run.FinishedAt == nil:
+		run.State = classifyRunStatus(run.PipelineTaskRuns, run.AllowPartialResults)
+	}
+	// When run.FinishedAt is already set, the caller (Runner) has
+	// decided the terminal run.State itself - the main DAG's status,
+	// computed before any finally tasks ran - so it's left alone here
+	// rather than reclassified over a PipelineTaskRuns slice that may
+	// now include finally's task runs too.
+
+	if _, err := ds.ExecContext(ctx, `
+		UPDATE pipeline_runs SET state = $1, finally_started_at = $2, finished_at = $3 WHERE id = $4
+	`, run.State, run.FinallyStartedAt, run.FinishedAt, run.ID); err != nil {
+		return false, err
+	}
+
+	for _, event := range taskEvents {
+		if err := o.events.Publish(ctx, event); err != nil {
+			return false, err
+		}
+	}
+	if run.State == RunStatusSuspended {
+		err = o.events.Publish(ctx, RunEvent{Type: RunEventRunSuspended, RunID: run.ID, State: run.State})
+	} else if run.State.Finished() {
+		err = o.events.Publish(ctx, RunEvent{Type: RunEventRunCompleted, RunID: run.ID, State: run.State})
+	}
+	return false, err
+}
+
+// classifyRunStatus derives a Run's overall status from its task runs:
+// still suspended if anything is unfinished, errored if anything failed
+// outright, partial if some tasks were skipped or errored but others
+// still produced a usable output, and completed otherwise. When
+// allowPartialResults is set, a genuine task error no longer forces the
+// whole run errored as long as some other task still succeeded; it's
+// carried as RunStatusPartial instead, with the failing task's Error
+// left intact for inspection.
+func classifyRunStatus(taskRuns []TaskRun, allowPartialResults bool) RunStatus {
+	allFinished := true
+	anyErrored := false
+	anySkippedOrErrored := false
+	anySucceeded := false
+	for _, tr := range taskRuns {
+		if !tr.FinishedAt.Valid {
+			allFinished = false
+			continue
+		}
+		switch {
+		case tr.Error.Valid:
+			anyErrored = true
+			anySkippedOrErrored = true
+		case tr.Skipped:
+			anySkippedOrErrored = true
+		default:
+			anySucceeded = true
+		}
+	}
+
+	switch {
+	case !allFinished:
+		return RunStatusSuspended
+	case anyErrored && !(allowPartialResults && anySucceeded):
+		return RunStatusErrored
+	case anySkippedOrErrored && anySucceeded:
+		return RunStatusPartial
+	default:
+		return RunStatusCompleted
+	}
+}
+
+// UpdateTaskRun records the result of a previously-suspended task (e.g.
+// an async bridge callback) and resumes the run. start reports whether
+// the caller should immediately re-run the DAG to pick up the new
+// result, which is always true on success.
+func (o *orm) UpdateTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, result interface{}) (Run, bool, error) {
+	return o.resumeTaskRun(ctx, ds, `
+		UPDATE pipeline_task_runs
+		SET output = $1, finished_at = $2
+		WHERE task_run_id = $3
+		RETURNING pipeline_run_id, dot_id
+	`, &JSONSerializable{Val: result}, taskRunID)
+}
+
+// FailTaskRun mirrors UpdateTaskRun for the case where a suspended
+// task's external handler reports failure rather than a result. As with
+// UpdateTaskRun, start is always true: the runner still needs to
+// re-evaluate the DAG, this time to decide whether the failure should
+// retry, skip, or error the run.
+func (o *orm) FailTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, taskErr string) (Run, bool, error) {
+	return o.resumeTaskRun(ctx, ds, `
+		UPDATE pipeline_task_runs
+		SET error = $1, finished_at = $2
+		WHERE task_run_id = $3
+		RETURNING pipeline_run_id, dot_id
+	`, taskErr, taskRunID)
+}
+
+func (o *orm) resumeTaskRun(ctx context.Context, ds sqlutil.DataStore, query string, value interface{}, taskRunID uuid.UUID) (Run, bool, error) {
+	now := time.Now()
+
+	var resumed struct {
+		PipelineRunID int64  `db:"pipeline_run_id"`
+		DotID         string `db:"dot_id"`
+	}
+	if err := ds.GetContext(ctx, &resumed, query, value, now, taskRunID); err != nil {
+		return Run{}, false, err
+	}
+
+	if _, err := ds.ExecContext(ctx, `UPDATE pipeline_runs SET state = $1 WHERE id = $2`, RunStatusRunning, resumed.PipelineRunID); err != nil {
+		return Run{}, false, err
+	}
+	if err := o.events.Publish(ctx, RunEvent{Type: RunEventTaskFinished, RunID: resumed.PipelineRunID, DotID: resumed.DotID}); err != nil {
+		return Run{}, false, err
+	}
+	if err := o.events.Publish(ctx, RunEvent{Type: RunEventRunResumed, RunID: resumed.PipelineRunID, State: RunStatusRunning}); err != nil {
+		return Run{}, false, err
+	}
+
+	run, err := o.FindRun(ctx, ds, resumed.PipelineRunID)
+	return run, true, err
+}
+
+// RetryTaskRun mirrors UpdateTaskRun but resets a failed task run for
+// another attempt instead of recording a final result: it increments
+// Attempts, clears the previous error and output, and reopens the task
+// (and the run, if it had already been marked errored) so the runner
+// picks it back up. start reports whether the run needs to be resumed
+// immediately, which is always true: a retry always has somewhere to go.
+func (o *orm) RetryTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID) (Run, bool, error) {
+	var pipelineRunID int64
+	err := ds.GetContext(ctx, &pipelineRunID, `
+		UPDATE pipeline_task_runs
+		SET output = NULL, error = NULL, finished_at = NULL, attempts = attempts + 1
+		WHERE task_run_id = $1
+		RETURNING pipeline_run_id
+	`, taskRunID)
+	if err != nil {
+		return Run{}, false, err
+	}
+
+	if _, err := ds.ExecContext(ctx, `UPDATE pipeline_runs SET state = $1 WHERE id = $2`, RunStatusRunning, pipelineRunID); err != nil {
+		return Run{}, false, err
+	}
+
+	run, err := o.FindRun(ctx, ds, pipelineRunID)
+	return run, true, err
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/bmizerany/assert"
+	"github.com/jmoiron/sqlx"
 	uuid "github.com/satori/go.uuid"
 	"github.com/smartcontractkit/chainlink/core/internal/cltest"
 	"github.com/smartcontractkit/chainlink/core/internal/testutils/pgtest"
@@ -14,10 +15,10 @@ import (
 	"github.com/smartcontractkit/chainlink/core/store/models"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
-	"gorm.io/gorm"
 )
 
 func Test_PipelineORM_CreateSpec(t *testing.T) {
+	ctx := context.Background()
 	db, orm := setupORM(t)
 
 	var (
@@ -29,44 +30,57 @@ func Test_PipelineORM_CreateSpec(t *testing.T) {
 		Source: source,
 	}
 
-	id, err := orm.CreateSpec(context.Background(), db, p, maxTaskDuration)
+	id, err := orm.CreateSpec(ctx, db, p, maxTaskDuration)
 	require.NoError(t, err)
 
-	actual := pipeline.Spec{}
-	err = db.Find(&actual, id).Error
+	var actual pipeline.Spec
+	err = db.GetContext(ctx, &actual, `SELECT * FROM pipeline_specs WHERE id = $1`, id)
 	require.NoError(t, err)
 	assert.Equal(t, source, actual.DotDagSource)
 	assert.Equal(t, maxTaskDuration, actual.MaxTaskDuration)
 }
 
 func Test_PipelineORM_FindRun(t *testing.T) {
+	ctx := context.Background()
 	db, orm := setupORM(t)
 
-	require.NoError(t, db.Exec(`SET CONSTRAINTS pipeline_runs_pipeline_spec_id_fkey DEFERRED`).Error)
-	expected := mustInsertPipelineRun(t, db)
+	specID := mustInsertPipelineSpec(t, ctx, db, orm)
+	expected := mustInsertPipelineRun(t, ctx, db, orm, specID)
 
-	run, err := orm.FindRun(expected.ID)
+	run, err := orm.FindRun(ctx, db, expected.ID)
 	require.NoError(t, err)
 
 	require.Equal(t, expected.ID, run.ID)
 }
 
-func mustInsertPipelineRun(t *testing.T, db *gorm.DB) pipeline.Run {
+func mustInsertPipelineSpec(t *testing.T, ctx context.Context, db *sqlx.DB, orm pipeline.ORM) int32 {
 	t.Helper()
 
-	run := pipeline.Run{
-		Outputs:    pipeline.JSONSerializable{Null: true},
-		Errors:     pipeline.RunErrors{},
-		FinishedAt: nil,
+	id, err := orm.CreateSpec(ctx, db, pipeline.Pipeline{}, models.Interval(0))
+	require.NoError(t, err)
+	return id
+}
+
+func mustInsertPipelineRun(t *testing.T, ctx context.Context, db *sqlx.DB, orm pipeline.ORM, specID int32) pipeline.Run {
+	t.Helper()
+
+	run := &pipeline.Run{
+		PipelineSpecID: specID,
+		Outputs:        pipeline.JSONSerializable{Null: true},
+		Errors:         pipeline.RunErrors{},
+		FinishedAt:     nil,
 	}
-	require.NoError(t, db.Create(&run).Error)
-	return run
+	require.NoError(t, orm.CreateRun(ctx, db, run))
+	return *run
 }
 
-func setupORM(t *testing.T) (*gorm.DB, pipeline.ORM) {
+func setupORM(t *testing.T) (*sqlx.DB, pipeline.ORM) {
 	t.Helper()
 
-	db := pgtest.NewGormDB(t)
+	gormDB := pgtest.NewGormDB(t)
+	sdb, err := gormDB.DB()
+	require.NoError(t, err)
+	db := postgres.WrapDbWithSqlx(sdb)
 	orm := pipeline.NewORM(db)
 
 	return db, orm
@@ -74,19 +88,18 @@ func setupORM(t *testing.T) (*gorm.DB, pipeline.ORM) {
 
 // Tests that inserting run results, then later updating the run results via upsert will work correctly.
 func Test_PipelineORM_StoreRun_ShouldUpsert(t *testing.T) {
+	ctx := context.Background()
 	db, orm := setupORM(t)
 
 	run := &pipeline.Run{
-		State:     pipeline.RunStatusRunning,
-		Errors:    nil,
-		Outputs:   pipeline.JSONSerializable{Null: true},
-		CreatedAt: time.Now(),
+		PipelineSpecID: mustInsertPipelineSpec(t, ctx, db, orm),
+		State:          pipeline.RunStatusRunning,
+		Errors:         nil,
+		Outputs:        pipeline.JSONSerializable{Null: true},
+		CreatedAt:      time.Now(),
 	}
 
-	// allow inserting without a spec
-	require.NoError(t, db.Exec(`SET CONSTRAINTS pipeline_runs_pipeline_spec_id_fkey DEFERRED`).Error)
-
-	err := orm.CreateRun(db, run)
+	err := orm.CreateRun(ctx, db, run)
 	require.NoError(t, err)
 
 	s := `
@@ -103,13 +116,8 @@ answer2 [type=bridge name=election_winner index=1];
 	require.NoError(t, err)
 	require.NotNil(t, p)
 
-	// spec := pipeline.Spec{DotDagSource: s}
-
 	now := time.Now()
 
-	sdb, err := orm.DB().DB()
-	require.NoError(t, err)
-
 	run.PipelineTaskRuns = []pipeline.TaskRun{
 		// pending task
 		{
@@ -131,14 +139,14 @@ answer2 [type=bridge name=election_winner index=1];
 			FinishedAt:    null.TimeFrom(now),
 		},
 	}
-	restart, err := orm.StoreRun(sdb, run)
+	restart, err := orm.StoreRun(ctx, db, run)
 	require.NoError(t, err)
 	// no new data, so we don't need a restart
 	require.Equal(t, false, restart)
 	// the run is paused
 	require.Equal(t, pipeline.RunStatusSuspended, run.State)
 
-	r, err := orm.FindRun(run.ID)
+	r, err := orm.FindRun(ctx, db, run.ID)
 	require.NoError(t, err)
 	run = &r
 	// this is an incomplete run, so partial results should be present (regardless of saveSuccessfulTaskRuns)
@@ -161,14 +169,14 @@ answer2 [type=bridge name=election_winner index=1];
 			FinishedAt:    null.TimeFrom(now),
 		},
 	}
-	restart, err = orm.StoreRun(sdb, run)
+	restart, err = orm.StoreRun(ctx, db, run)
 	require.NoError(t, err)
 	// no new data, so we don't need a restart
 	require.Equal(t, false, restart)
 	// the run is paused
 	require.Equal(t, pipeline.RunStatusSuspended, run.State)
 
-	r, err = orm.FindRun(run.ID)
+	r, err = orm.FindRun(ctx, db, run.ID)
 	require.NoError(t, err)
 	run = &r
 	// this is an incomplete run, so partial results should be present (regardless of saveSuccessfulTaskRuns)
@@ -181,27 +189,29 @@ answer2 [type=bridge name=election_winner index=1];
 // Tests that trying to persist a partial run while new data became available (i.e. via /v2/restart)
 // will detect a restart and update the result data on the Run.
 func Test_PipelineORM_StoreRun_DetectsRestarts(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := cltest.NewStore(t)
 	defer cleanup()
-	db := store.DB
+
+	sdb, err := store.DB.DB()
+	require.NoError(t, err)
+	db := postgres.WrapDbWithSqlx(sdb)
 
 	orm := pipeline.NewORM(db, store.Config)
 
 	run := &pipeline.Run{
-		State:     pipeline.RunStatusRunning,
-		Errors:    nil,
-		Inputs:    pipeline.JSONSerializable{Val: map[string]interface{}{"foo": "bar"}, Null: false},
-		Outputs:   pipeline.JSONSerializable{Null: true},
-		CreatedAt: time.Now(),
+		PipelineSpecID: mustInsertPipelineSpec(t, ctx, db, orm),
+		State:          pipeline.RunStatusRunning,
+		Errors:         nil,
+		Inputs:         pipeline.JSONSerializable{Val: map[string]interface{}{"foo": "bar"}, Null: false},
+		Outputs:        pipeline.JSONSerializable{Null: true},
+		CreatedAt:      time.Now(),
 	}
 
-	// allow inserting without a spec
-	require.NoError(t, db.Exec(`SET CONSTRAINTS pipeline_runs_pipeline_spec_id_fkey DEFERRED`).Error)
-
-	err := orm.CreateRun(db, run)
+	err = orm.CreateRun(ctx, db, run)
 	require.NoError(t, err)
 
-	r, err := orm.FindRun(run.ID)
+	r, err := orm.FindRun(ctx, db, run.ID)
 	require.NoError(t, err)
 	require.Equal(t, run.Inputs, r.Inputs)
 
@@ -221,15 +231,10 @@ answer2 [type=bridge name=election_winner index=1];
 
 	now := time.Now()
 
-	sdb, err := orm.DB().DB()
-	require.NoError(t, err)
-
 	ds1_id := uuid.NewV4()
 
-	sqlxDb := postgres.WrapDbWithSqlx(sdb)
-
 	// insert something for this pipeline_run to trigger an early resume while the pipeline is running
-	_, err = sqlxDb.NamedQuery(`
+	_, err = db.NamedQueryContext(ctx, `
 	INSERT INTO pipeline_task_runs (pipeline_run_id, task_run_id, type, index, output, error, dot_id, created_at, finished_at)
 	VALUES (:pipeline_run_id, :task_run_id, :type, :index, :output, :error, :dot_id, :created_at, :finished_at)
 	`, pipeline.TaskRun{
@@ -265,7 +270,7 @@ answer2 [type=bridge name=election_winner index=1];
 		},
 	}
 
-	restart, err := orm.StoreRun(sdb, run)
+	restart, err := orm.StoreRun(ctx, db, run)
 	require.NoError(t, err)
 	// new data available! immediately restart the run
 	require.Equal(t, true, restart)
@@ -277,29 +282,28 @@ answer2 [type=bridge name=election_winner index=1];
 }
 
 func Test_PipelineORM_StoreRun_UpdateTaskRun(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := cltest.NewStore(t)
 	defer cleanup()
-	db := store.DB
 
-	orm := pipeline.NewORM(db, store.Config)
-
-	sdb, err := orm.DB().DB()
+	sdb, err := store.DB.DB()
 	require.NoError(t, err)
+	db := postgres.WrapDbWithSqlx(sdb)
+
+	orm := pipeline.NewORM(db, store.Config)
 
 	now := time.Now()
 
 	run := &pipeline.Run{
-		State:     pipeline.RunStatusRunning,
-		Errors:    nil,
-		Outputs:   pipeline.JSONSerializable{Null: true},
-		CreatedAt: now,
+		PipelineSpecID: mustInsertPipelineSpec(t, ctx, db, orm),
+		State:          pipeline.RunStatusRunning,
+		Errors:         nil,
+		Outputs:        pipeline.JSONSerializable{Null: true},
+		CreatedAt:      now,
 	}
 
-	// allow inserting without a spec
-	require.NoError(t, db.Exec(`SET CONSTRAINTS pipeline_runs_pipeline_spec_id_fkey DEFERRED`).Error)
-
 	// Create a run with a "running" state
-	err = orm.CreateRun(db, run)
+	err = orm.CreateRun(ctx, db, run)
 	require.NoError(t, err)
 
 	ds1_id := uuid.NewV4()
@@ -328,13 +332,13 @@ func Test_PipelineORM_StoreRun_UpdateTaskRun(t *testing.T) {
 	require.Equal(t, pipeline.RunStatusRunning, run.State)
 
 	// Now store a partial run
-	restart, err := orm.StoreRun(sdb, run)
+	restart, err := orm.StoreRun(ctx, db, run)
 	require.NoError(t, err)
 	require.False(t, restart)
 	// assert that run should be in "paused" state
 	require.Equal(t, pipeline.RunStatusSuspended, run.State)
 
-	r, start, err := orm.UpdateTaskRun(sdb, ds1_id, "foo")
+	r, start, err := orm.UpdateTaskRun(ctx, db, ds1_id, "foo")
 	run = &r
 	require.NoError(t, err)
 	require.Len(t, run.PipelineTaskRuns, 2)
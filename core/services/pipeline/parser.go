@@ -0,0 +1,365 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Pipeline is the in-memory representation of a parsed DOT spec: every
+// task node, reachable from its dependencies in the order the runner
+// should evaluate them.
+type Pipeline struct {
+	Source string
+	Tasks  []Task
+
+	// AllowPartialResults mirrors Spec.AllowPartialResults; Parse never
+	// sets it since it's a spec-level, not per-task, setting — callers
+	// populate it from the Spec before handing the Pipeline to Runner.
+	AllowPartialResults bool
+}
+
+// ByDotID returns the task with the given DOT id, or nil.
+func (p *Pipeline) ByDotID(id string) Task {
+	for _, t := range p.Tasks {
+		if t.DotID() == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// pipelineNode is the intermediate representation built while scanning
+// the DOT source, before attributes are converted into a concrete Task.
+type pipelineNode struct {
+	dotID string
+	attrs map[string]string
+	// inputs holds the DOT ids that feed this node, in the order their
+	// edges were declared.
+	inputs []string
+	// final marks a node declared inside a `finally { ... }` block.
+	final bool
+}
+
+// Parse reads a DOT-like pipeline spec of the form used by job specs:
+//
+//	ds1 [type=bridge name="foo" requestData=<{"a": 1}>]
+//	ds1_parse [type=jsonparse path="data,result"]
+//	ds1 -> ds1_parse -> answer1;
+//	answer1 [type=median index=0];
+//
+//	finally {
+//		notify [type=bridge name="slack" requestData=<{"status": "$(tasks.answer1.status)"}>];
+//	}
+//
+// and returns the Pipeline it describes. Tasks inside a finally block, or
+// carrying a `final=true` attribute, always run after the main DAG
+// regardless of its outcome.
+func Parse(source string) (*Pipeline, error) {
+	nodes := map[string]*pipelineNode{}
+	order := []string{}
+
+	getNode := func(id string) *pipelineNode {
+		n, ok := nodes[id]
+		if !ok {
+			n = &pipelineNode{dotID: id, attrs: map[string]string{}}
+			nodes[id] = n
+			order = append(order, id)
+		}
+		return n
+	}
+
+	s := newScanner(source)
+	if err := parseStatements(s, getNode, false); err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{Source: source}
+	for _, id := range order {
+		n := nodes[id]
+		if n.final {
+			n.attrs["final"] = "true"
+		}
+		t, err := newTask(n)
+		if err != nil {
+			return nil, err
+		}
+		p.Tasks = append(p.Tasks, t)
+	}
+	return p, nil
+}
+
+// parseStatements reads node and edge statements until EOF, or until a
+// closing '}' if inFinally is true (in which case it's consumed as the
+// end of a finally block). Every node it creates is marked final when
+// inFinally is set.
+func parseStatements(s *scanner, getNode func(string) *pipelineNode, inFinally bool) error {
+	for {
+		s.skipSpace()
+		if s.eof() {
+			if inFinally {
+				return fmt.Errorf("pipeline: unterminated finally block")
+			}
+			return nil
+		}
+		if inFinally && s.consume("}") {
+			return nil
+		}
+
+		id := s.readIdent()
+		if id == "" {
+			return fmt.Errorf("pipeline: unexpected character %q at offset %d", s.peekRune(), s.pos)
+		}
+		s.skipSpace()
+
+		if id == "finally" && s.consume("{") {
+			if err := parseStatements(s, getNode, true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if s.consume("->") {
+			// Edge chain: id -> id2 -> id3 ...
+			from := id
+			for {
+				s.skipSpace()
+				to := s.readIdent()
+				if to == "" {
+					return fmt.Errorf("pipeline: expected task id after '->'")
+				}
+				getNode(to).inputs = append(getNode(to).inputs, from)
+				getNode(from).final = getNode(from).final || inFinally
+				getNode(to).final = getNode(to).final || inFinally
+				from = to
+				s.skipSpace()
+				if !s.consume("->") {
+					break
+				}
+			}
+			s.skipSpace()
+			s.consume(";")
+			continue
+		}
+
+		if s.consume("[") {
+			n := getNode(id)
+			n.final = n.final || inFinally
+			if err := s.readAttrs(n.attrs); err != nil {
+				return err
+			}
+			s.skipSpace()
+			s.consume(";")
+			continue
+		}
+
+		getNode(id).final = getNode(id).final || inFinally
+		s.consume(";")
+	}
+}
+
+// newTask converts a parsed node's attributes into the concrete Task
+// implementation named by its "type" attribute.
+func newTask(n *pipelineNode) (Task, error) {
+	base := BaseTask{
+		dotID:      n.dotID,
+		Index:      int32(atoiDefault(n.attrs["index"], 0)),
+		Inputs:     n.inputs,
+		SkipOnFail: n.attrs["skipOnFail"] == "true",
+		Final:      n.attrs["final"] == "true",
+	}
+
+	if retries, ok := n.attrs["retries"]; ok {
+		r, err := strconv.ParseUint(retries, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: task %q has invalid retries attribute: %w", n.dotID, err)
+		}
+		base.Retries = uint32(r)
+		base.Backoff = BackoffType(n.attrs["backoff"])
+		if d, ok := n.attrs["minBackoff"]; ok {
+			parsed, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline: task %q has invalid minBackoff: %w", n.dotID, err)
+			}
+			base.MinBackoff = parsed
+		}
+		if d, ok := n.attrs["maxBackoff"]; ok {
+			parsed, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline: task %q has invalid maxBackoff: %w", n.dotID, err)
+			}
+			base.MaxBackoff = parsed
+		}
+	}
+
+	switch TaskType(n.attrs["type"]) {
+	case TaskTypeBridge:
+		timeout, _ := time.ParseDuration(n.attrs["timeout"])
+		return &BridgeTask{
+			BaseTask:    base,
+			Name:        n.attrs["name"],
+			RequestData: n.attrs["requestData"],
+			Async:       n.attrs["async"] == "true",
+			Timeout:     timeout,
+		}, nil
+	case TaskTypeJSONParse:
+		return &JSONParseTask{
+			BaseTask: base,
+			Path:     n.attrs["path"],
+			Lax:      n.attrs["lax"] == "true",
+		}, nil
+	case TaskTypeMultiply:
+		times, err := decimal.NewFromString(n.attrs["times"])
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: task %q has invalid times attribute: %w", n.dotID, err)
+		}
+		return &MultiplyTask{BaseTask: base, Times: times}, nil
+	case TaskTypeMedian:
+		return &MedianTask{BaseTask: base}, nil
+	case TaskTypeCustom:
+		ref, ok := n.attrs["ref"]
+		if !ok || ref == "" {
+			return nil, fmt.Errorf("pipeline: custom task %q is missing a ref attribute", n.dotID)
+		}
+		return &CustomTask{BaseTask: base, Ref: ref, Spec: n.attrs["spec"]}, nil
+	default:
+		return nil, fmt.Errorf("pipeline: task %q has unknown or missing type %q", n.dotID, n.attrs["type"])
+	}
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// scanner is a minimal hand-rolled lexer for the DOT dialect above; it
+// doesn't aim to support general Graphviz DOT, only the subset our job
+// specs use.
+type scanner struct {
+	src string
+	pos int
+}
+
+func newScanner(src string) *scanner { return &scanner{src: src} }
+
+func (s *scanner) eof() bool { return s.pos >= len(s.src) }
+
+func (s *scanner) peekRune() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.src[s.pos]
+}
+
+func (s *scanner) skipSpace() {
+	for !s.eof() {
+		c := s.src[s.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			s.pos++
+			continue
+		}
+		break
+	}
+}
+
+func (s *scanner) consume(tok string) bool {
+	if strings.HasPrefix(s.src[s.pos:], tok) {
+		s.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (s *scanner) readIdent() string {
+	start := s.pos
+	for !s.eof() && isIdentByte(s.src[s.pos]) {
+		s.pos++
+	}
+	return s.src[start:s.pos]
+}
+
+// readAttrs reads `key=value key2="value2" ...]` up to and including the
+// closing bracket, storing each pair into attrs.
+func (s *scanner) readAttrs(attrs map[string]string) error {
+	for {
+		s.skipSpace()
+		if s.consume("]") {
+			return nil
+		}
+		if s.eof() {
+			return fmt.Errorf("pipeline: unterminated attribute list")
+		}
+		key := s.readIdent()
+		if key == "" {
+			return fmt.Errorf("pipeline: expected attribute name at offset %d", s.pos)
+		}
+		s.skipSpace()
+		if !s.consume("=") {
+			return fmt.Errorf("pipeline: expected '=' after attribute %q", key)
+		}
+		s.skipSpace()
+		val, err := s.readValue()
+		if err != nil {
+			return err
+		}
+		attrs[key] = val
+	}
+}
+
+func (s *scanner) readValue() (string, error) {
+	switch s.peekRune() {
+	case '"':
+		s.pos++
+		start := s.pos
+		for !s.eof() && s.src[s.pos] != '"' {
+			if s.src[s.pos] == '\\' {
+				s.pos++
+			}
+			s.pos++
+		}
+		if s.eof() {
+			return "", fmt.Errorf("pipeline: unterminated quoted string")
+		}
+		val := s.src[start:s.pos]
+		s.pos++ // closing quote
+		return val, nil
+	case '<':
+		depth := 0
+		start := s.pos
+		for !s.eof() {
+			switch s.src[s.pos] {
+			case '<':
+				depth++
+			case '>':
+				depth--
+				if depth == 0 {
+					val := s.src[start+1 : s.pos]
+					s.pos++ // closing '>'
+					return val, nil
+				}
+			}
+			s.pos++
+		}
+		return "", fmt.Errorf("pipeline: unterminated raw (<...>) value")
+	default:
+		start := s.pos
+		for !s.eof() && s.src[s.pos] != ' ' && s.src[s.pos] != '\t' && s.src[s.pos] != '\n' &&
+			s.src[s.pos] != ']' && s.src[s.pos] != ',' {
+			s.pos++
+		}
+		return s.src[start:s.pos], nil
+	}
+}
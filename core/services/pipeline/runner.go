@@ -0,0 +1,219 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Runner walks a Pipeline's DAG in dependency order, persisting task
+// results as it goes and retrying individual tasks per their declared
+// retry policy before the run as a whole is marked errored.
+type Runner struct {
+	orm ORM
+}
+
+func NewRunner(orm ORM) *Runner {
+	return &Runner{orm: orm}
+}
+
+// ExecuteAndSave runs every not-yet-finished task in pipeline against
+// run, then persists the result via ORM.StoreRun. Tasks in the pipeline's
+// finally block run afterwards unconditionally, even if the main DAG
+// errored, with access to the main DAG's status and outputs via
+// $(tasks.<dot_id>.status)/$(tasks.<dot_id>.out) substitution.
+func (r *Runner) ExecuteAndSave(ctx context.Context, ds sqlutil.DataStore, pipeline *Pipeline, run *Run) error {
+	run.AllowPartialResults = pipeline.AllowPartialResults
+	varsByDotID := map[string]Result{}
+
+	var mainTasks, finallyTasks []Task
+	for _, task := range pipeline.Tasks {
+		if task.Base().Final {
+			finallyTasks = append(finallyTasks, task)
+		} else {
+			mainTasks = append(mainTasks, task)
+		}
+	}
+
+	suspended, err := r.runTasks(ctx, ds, pipeline, run, mainTasks, varsByDotID)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		// A task (e.g. an async bridge or custom task) is awaiting an
+		// external callback. Persist the pending state and return;
+		// ExecuteAndSave will be re-invoked once that callback arrives
+		// via ORM.UpdateTaskRun. The finally block must wait for the
+		// main DAG to actually finish, so it doesn't run yet.
+		_, err := r.orm.StoreRun(ctx, ds, run)
+		return err
+	}
+
+	// Computed from the main DAG alone, before any finally tasks run, so
+	// neither their var substitution nor the run's own terminal status
+	// depends on how cleanup/notification tasks turn out.
+	var mainTaskRuns []TaskRun
+	for _, task := range mainTasks {
+		if tr := run.ByDotID(task.DotID()); tr != nil {
+			mainTaskRuns = append(mainTaskRuns, *tr)
+		}
+	}
+	mainStatus := classifyRunStatus(mainTaskRuns, run.AllowPartialResults)
+
+	if len(finallyTasks) > 0 {
+		if run.FinallyStartedAt == nil {
+			now := time.Now()
+			run.FinallyStartedAt = &now
+		}
+		// Persist main-DAG results and the finally start time before
+		// running finally tasks. run.FinishedAt stays nil, so StoreRun
+		// keeps the run non-terminal per the invariant documented on
+		// Run.FinallyStartedAt, even though every main-DAG task is done
+		// - observers must never see a terminal run while cleanup or
+		// notification tasks are still in flight.
+		if _, err := r.orm.StoreRun(ctx, ds, run); err != nil {
+			return err
+		}
+
+		substituteRunVars(finallyTasks, run, mainStatus)
+
+		suspended, err := r.runTasks(ctx, ds, pipeline, run, finallyTasks, varsByDotID)
+		if err != nil {
+			return err
+		}
+		if suspended {
+			// A finally task is awaiting an external callback; the run
+			// stays non-terminal until it resumes and finishes.
+			_, err := r.orm.StoreRun(ctx, ds, run)
+			return err
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.State = mainStatus
+	_, err = r.orm.StoreRun(ctx, ds, run)
+	return err
+}
+
+// runTasks executes tasks in order, resolving each one's inputs from
+// varsByDotID and recording its TaskRun outcome, skipping any that
+// already finished (e.g. on a resumed run). It stops, reporting
+// suspended=true, as soon as a task suspends awaiting an external
+// callback (ErrTaskSuspended), leaving that task and everything after it
+// pending.
+func (r *Runner) runTasks(ctx context.Context, ds sqlutil.DataStore, pipeline *Pipeline, run *Run, tasks []Task, varsByDotID map[string]Result) (suspended bool, err error) {
+	for _, task := range tasks {
+		tr := run.ByDotID(task.DotID())
+		if tr == nil {
+			run.PipelineTaskRuns = append(run.PipelineTaskRuns, TaskRun{
+				PipelineRunID: run.ID,
+				TaskRunID:     uuid.NewV4(),
+				Type:          task.Type(),
+				DotID:         task.DotID(),
+				Index:         task.Base().Index,
+				CreatedAt:     time.Now(),
+			})
+			tr = &run.PipelineTaskRuns[len(run.PipelineTaskRuns)-1]
+		}
+
+		if tr.FinishedAt.Valid {
+			varsByDotID[task.DotID()] = resultFromTaskRun(*tr)
+			continue
+		}
+
+		inputs := make([]Result, len(task.Base().Inputs))
+		for i, in := range task.Base().Inputs {
+			inputs[i] = varsByDotID[in]
+		}
+
+		// Persist the pending row up front so a mid-retry RetryTaskRun
+		// call below has something to update.
+		if task.Base().Retries > 0 {
+			if _, err := r.orm.StoreRun(ctx, ds, run); err != nil {
+				return false, err
+			}
+		}
+
+		result := r.runWithRetries(ctx, ds, task, tr, inputs)
+		if errors.Is(result.Error, ErrTaskSuspended) {
+			return true, nil
+		}
+		varsByDotID[task.DotID()] = result
+
+		tr.FinishedAt = null.TimeFrom(time.Now())
+		switch {
+		case result.Error != nil && (task.Base().SkipOnFail || dependsOnSkipped(task, run)):
+			tr.Skipped = true
+		case result.Error != nil:
+			tr.Error = null.StringFrom(result.Error.Error())
+		default:
+			tr.Output = &JSONSerializable{Val: result.Value}
+		}
+	}
+	return false, nil
+}
+
+// runWithRetries calls task.Run, and for tasks with a nonzero retry
+// budget, reschedules on failure via ORM.RetryTaskRun (waiting out the
+// task's backoff) until either it succeeds or attempts are exhausted.
+// Suspension (ErrTaskSuspended) is passed straight through: it isn't a
+// failure, so it isn't retried.
+func (r *Runner) runWithRetries(ctx context.Context, ds sqlutil.DataStore, task Task, tr *TaskRun, inputs []Result) Result {
+	base := task.Base()
+
+	for {
+		result := task.Run(ctx, tr.TaskRunID, inputs)
+		if result.Error == nil || errors.Is(result.Error, ErrTaskSuspended) {
+			return result
+		}
+		if !base.CanRetry(tr.Attempts) {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			return Result{Error: ctx.Err()}
+		case <-time.After(base.RetryDelay(tr.Attempts + 1)):
+		}
+
+		run, _, err := r.orm.RetryTaskRun(ctx, ds, tr.TaskRunID)
+		if err != nil {
+			return Result{Error: err}
+		}
+		if retried := run.ByDotID(task.DotID()); retried != nil {
+			tr.Attempts = retried.Attempts
+		}
+	}
+}
+
+// dependsOnSkipped reports whether any of task's inputs were themselves
+// skipped or errored, so a failure here should propagate as a skip
+// rather than erroring the whole run.
+func dependsOnSkipped(task Task, run *Run) bool {
+	for _, in := range task.Base().Inputs {
+		if itr := run.ByDotID(in); itr != nil && (itr.Skipped || itr.Error.Valid) {
+			return true
+		}
+	}
+	return false
+}
+
+func resultFromTaskRun(tr TaskRun) Result {
+	if tr.Error.Valid {
+		return Result{Error: taskRunError{tr.Error.String}}
+	}
+	var val interface{}
+	if tr.Output != nil {
+		val = tr.Output.Val
+	}
+	return Result{Value: val}
+}
+
+type taskRunError struct{ msg string }
+
+func (e taskRunError) Error() string { return e.msg }
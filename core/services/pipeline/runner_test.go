@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/smartcontractkit/chainlink/core/store/models"
+	"github.com/smartcontractkit/chainlink/core/utils/sqlutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+// fakeTask is a minimal Task whose Run behavior is supplied by the test,
+// so retry logic can be exercised without a real bridge/http task or a
+// database.
+type fakeTask struct {
+	BaseTask
+	run   func(attempt int) Result
+	calls int
+}
+
+func (t *fakeTask) Type() TaskType { return "fake" }
+
+func (t *fakeTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	t.calls++
+	return t.run(t.calls)
+}
+
+func newFakeTask(dotID string, base BaseTask, run func(attempt int) Result) *fakeTask {
+	base.dotID = dotID
+	return &fakeTask{BaseTask: base, run: run}
+}
+
+// noopORM implements the ORM methods fakeORM doesn't need to override,
+// so fakeORM only has to provide the couple that matter for a given test.
+type noopORM struct{}
+
+func (noopORM) CreateSpec(ctx context.Context, ds sqlutil.DataStore, p Pipeline, d models.Interval) (int32, error) {
+	return 0, nil
+}
+func (noopORM) CreateRun(ctx context.Context, ds sqlutil.DataStore, run *Run) error { return nil }
+func (noopORM) FindRun(ctx context.Context, ds sqlutil.DataStore, id int64) (Run, error) {
+	return Run{}, nil
+}
+func (noopORM) UpdateTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, result interface{}) (Run, bool, error) {
+	return Run{}, false, nil
+}
+func (noopORM) FailTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID, taskErr string) (Run, bool, error) {
+	return Run{}, false, nil
+}
+
+// storeCall snapshots the state StoreRun left a run in, so a test can
+// check what got "persisted" at each intermediate call, not just the
+// final one ExecuteAndSave returns with.
+type storeCall struct {
+	state          RunStatus
+	finallyStarted bool
+	finished       bool
+}
+
+// fakeORM is an in-memory stand-in for ORM: it remembers the
+// last-stored TaskRuns by TaskRunID so RetryTaskRun can hand attempt
+// counts back the way the real ORM does, and it mirrors orm.StoreRun's
+// FinallyStartedAt/FinishedAt gating so tests can assert a run is never
+// left looking terminal while its finally block is still in flight.
+type fakeORM struct {
+	noopORM
+	mu     sync.Mutex
+	tasks  map[uuid.UUID]TaskRun
+	stores []storeCall
+}
+
+func newFakeORM() *fakeORM {
+	return &fakeORM{tasks: map[uuid.UUID]TaskRun{}}
+}
+
+func (f *fakeORM) StoreRun(ctx context.Context, ds sqlutil.DataStore, run *Run) (bool, error) {
+	f.mu.Lock()
+	for _, tr := range run.PipelineTaskRuns {
+		f.tasks[tr.TaskRunID] = tr
+	}
+	f.mu.Unlock()
+
+	switch {
+	case run.FinallyStartedAt != nil && run.FinishedAt == nil:
+		run.State = RunStatusRunning
+	case run.FinishedAt == nil:
+		run.State = classifyRunStatus(run.PipelineTaskRuns, run.AllowPartialResults)
+	}
+
+	f.mu.Lock()
+	f.stores = append(f.stores, storeCall{
+		state:          run.State,
+		finallyStarted: run.FinallyStartedAt != nil,
+		finished:       run.FinishedAt != nil,
+	})
+	f.mu.Unlock()
+
+	return false, nil
+}
+
+func (f *fakeORM) RetryTaskRun(ctx context.Context, ds sqlutil.DataStore, taskRunID uuid.UUID) (Run, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tr := f.tasks[taskRunID]
+	tr.Attempts++
+	tr.FinishedAt = null.Time{}
+	tr.Error = null.String{}
+	f.tasks[taskRunID] = tr
+
+	return Run{PipelineTaskRuns: []TaskRun{tr}}, true, nil
+}
+
+var errFakeTask = errors.New("fake task failure")
+
+func Test_Runner_RetryExhaustion(t *testing.T) {
+	orm := newFakeORM()
+	runner := NewRunner(orm)
+
+	task := newFakeTask("t1", BaseTask{Retries: 2}, func(attempt int) Result {
+		return Result{Error: errFakeTask}
+	})
+	p := &Pipeline{Tasks: []Task{task}}
+	run := &Run{}
+
+	err := runner.ExecuteAndSave(context.Background(), nil, p, run)
+	require.NoError(t, err)
+
+	// retries=2 means up to 3 total attempts before the task gives up.
+	require.Equal(t, 3, task.calls)
+	tr := run.ByDotID("t1")
+	require.NotNil(t, tr)
+	require.True(t, tr.Error.Valid)
+	require.Equal(t, errFakeTask.Error(), tr.Error.String)
+	require.Equal(t, RunStatusErrored, run.State)
+}
+
+func Test_Runner_RetrySucceedsBeforeExhaustion(t *testing.T) {
+	orm := newFakeORM()
+	runner := NewRunner(orm)
+
+	task := newFakeTask("t1", BaseTask{Retries: 2}, func(attempt int) Result {
+		if attempt < 2 {
+			return Result{Error: errFakeTask}
+		}
+		return Result{Value: "ok"}
+	})
+	p := &Pipeline{Tasks: []Task{task}}
+	run := &Run{}
+
+	err := runner.ExecuteAndSave(context.Background(), nil, p, run)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, task.calls)
+	tr := run.ByDotID("t1")
+	require.NotNil(t, tr)
+	require.False(t, tr.Error.Valid)
+	require.Equal(t, RunStatusCompleted, run.State)
+}
+
+func Test_Runner_SkipOnFailPropagatesToDependents(t *testing.T) {
+	orm := newFakeORM()
+	runner := NewRunner(orm)
+
+	upstream := newFakeTask("upstream", BaseTask{SkipOnFail: true}, func(attempt int) Result {
+		return Result{Error: errFakeTask}
+	})
+	downstream := newFakeTask("downstream", BaseTask{Inputs: []string{"upstream"}}, func(attempt int) Result {
+		return Result{Error: errFakeTask}
+	})
+	other := newFakeTask("other", BaseTask{}, func(attempt int) Result {
+		return Result{Value: "ok"}
+	})
+	p := &Pipeline{Tasks: []Task{upstream, downstream, other}}
+	run := &Run{}
+
+	err := runner.ExecuteAndSave(context.Background(), nil, p, run)
+	require.NoError(t, err)
+
+	upTR := run.ByDotID("upstream")
+	require.True(t, upTR.Skipped)
+	require.False(t, upTR.Error.Valid)
+
+	downTR := run.ByDotID("downstream")
+	require.True(t, downTR.Skipped)
+	require.False(t, downTR.Error.Valid)
+
+	require.Equal(t, RunStatusPartial, run.State)
+}
+
+func Test_Runner_AllowPartialResultsKeepsGenuineTaskError(t *testing.T) {
+	orm := newFakeORM()
+	runner := NewRunner(orm)
+
+	failing := newFakeTask("failing", BaseTask{}, func(attempt int) Result {
+		return Result{Error: errFakeTask}
+	})
+	succeeding := newFakeTask("succeeding", BaseTask{}, func(attempt int) Result {
+		return Result{Value: "ok"}
+	})
+	p := &Pipeline{Tasks: []Task{failing, succeeding}, AllowPartialResults: true}
+	run := &Run{}
+
+	err := runner.ExecuteAndSave(context.Background(), nil, p, run)
+	require.NoError(t, err)
+
+	// A task that genuinely errors keeps its Error recorded even under
+	// AllowPartialResults; it must not be laundered into Skipped.
+	failTR := run.ByDotID("failing")
+	require.False(t, failTR.Skipped)
+	require.True(t, failTR.Error.Valid)
+	require.Equal(t, errFakeTask.Error(), failTR.Error.String)
+
+	require.Equal(t, RunStatusPartial, run.State)
+}
+
+func Test_Runner_FinallyBlockRunsAfterMainDAGAndRunOnlyTerminatesAfterward(t *testing.T) {
+	orm := newFakeORM()
+	runner := NewRunner(orm)
+
+	main := newFakeTask("main", BaseTask{}, func(attempt int) Result {
+		return Result{Value: "ok"}
+	})
+	// The finally task's own outcome (here, an error) must not flip the
+	// run's terminal status away from what the main DAG alone produced.
+	cleanup := newFakeTask("cleanup", BaseTask{Final: true}, func(attempt int) Result {
+		return Result{Error: errFakeTask}
+	})
+	p := &Pipeline{Tasks: []Task{main, cleanup}}
+	run := &Run{}
+
+	err := runner.ExecuteAndSave(context.Background(), nil, p, run)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, main.calls)
+	require.Equal(t, 1, cleanup.calls)
+
+	cleanupTR := run.ByDotID("cleanup")
+	require.NotNil(t, cleanupTR)
+	require.True(t, cleanupTR.Error.Valid)
+
+	// The finally block ran and errored, but the run's overall status
+	// still reflects the main DAG (which completed cleanly), and the
+	// run is now genuinely terminal.
+	require.Equal(t, RunStatusCompleted, run.State)
+	require.NotNil(t, run.FinallyStartedAt)
+	require.NotNil(t, run.FinishedAt)
+
+	// Every StoreRun call made while the finally block was pending (i.e.
+	// FinallyStartedAt set but FinishedAt not yet) must have persisted a
+	// non-terminal state, even though every main-DAG task had already
+	// finished by then.
+	require.True(t, len(orm.stores) >= 2)
+	for _, s := range orm.stores[:len(orm.stores)-1] {
+		if s.finallyStarted && !s.finished {
+			require.False(t, s.state.Finished(), "run must not look terminal while the finally block is still in flight")
+		}
+	}
+	last := orm.stores[len(orm.stores)-1]
+	require.True(t, last.finished)
+	require.True(t, last.state.Finished())
+}
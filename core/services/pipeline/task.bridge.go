@@ -0,0 +1,38 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// BridgeTask dispatches a request to a named external adapter (bridge)
+// and, when Async is set, suspends the run until the adapter calls back
+// via UpdateTaskRun.
+type BridgeTask struct {
+	BaseTask
+
+	Name        string
+	RequestData string
+	Async       bool
+	Timeout     time.Duration
+}
+
+var _ Task = (*BridgeTask)(nil)
+
+func (t *BridgeTask) Type() TaskType { return TaskTypeBridge }
+
+func (t *BridgeTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	if t.Async {
+		// The real implementation POSTs RequestData to the bridge's URL
+		// and returns immediately; the adapter resumes the run later by
+		// calling ORM.UpdateTaskRun with taskRunID. HTTP transport lives
+		// outside this package, so dispatch itself is left unimplemented
+		// here.
+		return Result{Error: ErrTaskSuspended}
+	}
+	// Synchronous bridges round-trip within Run; also left unimplemented
+	// since HTTP transport lives outside this package.
+	return Result{Error: ErrNotImplemented}
+}
@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Result is what a Task.Run call produces: either a value to hand to
+// downstream tasks, or an error if the task failed.
+type Result struct {
+	Value interface{}
+	Error error
+}
+
+// BackoffType selects how RetryDelay grows between attempts.
+type BackoffType string
+
+const (
+	BackoffTypeNone        BackoffType = ""
+	BackoffTypeFixed       BackoffType = "fixed"
+	BackoffTypeExponential BackoffType = "exponential"
+)
+
+// Task is implemented by every DAG node type (bridge, http, median, ...).
+// Runner walks the DAG calling Run on each task once its dependencies
+// have produced values.
+type Task interface {
+	Type() TaskType
+	DotID() string
+	Base() *BaseTask
+	// Run executes the task. taskRunID identifies the persisted
+	// TaskRun row, needed by task types (bridge, custom) that suspend
+	// execution and resume later via an external callback referencing
+	// it.
+	Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result
+}
+
+// BaseTask holds the attributes common to every DOT task node, including
+// the retry policy. Concrete task types embed it.
+type BaseTask struct {
+	dotID string
+	Index int32
+
+	// Inputs is the DOT ids this task depends on, in edge declaration
+	// order; the runner resolves these to Results before calling Run.
+	Inputs []string
+
+	// Retries is the number of additional attempts allowed after the
+	// first failure (retries=3 means up to 4 total attempts). Zero
+	// means the task fails the run immediately, matching the
+	// pre-existing behavior.
+	Retries uint32
+
+	// Backoff controls the delay before each retry. MinBackoff is used
+	// as-is for BackoffTypeFixed; for BackoffTypeExponential the delay
+	// doubles every attempt up to MaxBackoff.
+	Backoff    BackoffType
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// SkipOnFail, when true, lets a failure in this task (after retries
+	// are exhausted) mark it and any task that depends solely on it as
+	// Skipped instead of erroring the whole run.
+	SkipOnFail bool
+
+	// Final marks a task as part of the pipeline's finally block: it
+	// runs after the main DAG regardless of that DAG's outcome, and
+	// doesn't itself affect whether the main DAG is considered errored.
+	Final bool
+}
+
+func (t *BaseTask) DotID() string { return t.dotID }
+func (t *BaseTask) Base() *BaseTask { return t }
+
+// RetryDelay returns how long to wait after attempt number `attempt`
+// (1-indexed; the attempt that just failed) before starting the next one.
+func (t *BaseTask) RetryDelay(attempt uint32) time.Duration {
+	switch t.Backoff {
+	case BackoffTypeExponential:
+		d := t.MinBackoff * time.Duration(math.Pow(2, float64(attempt-1)))
+		if t.MaxBackoff > 0 && d > t.MaxBackoff {
+			return t.MaxBackoff
+		}
+		return d
+	case BackoffTypeFixed:
+		return t.MinBackoff
+	default:
+		return 0
+	}
+}
+
+// CanRetry reports whether another attempt is allowed after `attempts`
+// retries (TaskRun.Attempts) have already been made.
+func (t *BaseTask) CanRetry(attempts uint32) bool {
+	return attempts < t.Retries
+}
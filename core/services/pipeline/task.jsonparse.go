@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// JSONParseTask extracts a value from its single dependency's JSON output
+// at the given comma-separated Path.
+type JSONParseTask struct {
+	BaseTask
+
+	Path string
+	// Lax, when true, returns a nil value instead of an error if Path
+	// doesn't exist in the input.
+	Lax bool
+}
+
+var _ Task = (*JSONParseTask)(nil)
+
+func (t *JSONParseTask) Type() TaskType { return TaskTypeJSONParse }
+
+func (t *JSONParseTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	if len(vars) == 0 || vars[0].Error != nil {
+		return Result{Error: fmt.Errorf("jsonparse task: no input to parse")}
+	}
+
+	var parsed interface{}
+	switch input := vars[0].Value.(type) {
+	case []byte:
+		if err := json.Unmarshal(input, &parsed); err != nil {
+			return Result{Error: err}
+		}
+	default:
+		parsed = input
+	}
+
+	cur := parsed
+	for _, key := range strings.Split(t.Path, ",") {
+		if key == "" {
+			continue
+		}
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			if t.Lax {
+				return Result{Value: nil}
+			}
+			return Result{Error: fmt.Errorf("jsonparse task: could not resolve path %q", t.Path)}
+		}
+		cur, ok = m[key]
+		if !ok {
+			if t.Lax {
+				return Result{Value: nil}
+			}
+			return Result{Error: fmt.Errorf("jsonparse task: key %q not found", key)}
+		}
+	}
+	return Result{Value: cur}
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MedianTask reduces its dependencies' outputs to their median, the
+// standard aggregation task for OCR/Flux jobs.
+type MedianTask struct {
+	BaseTask
+}
+
+var _ Task = (*MedianTask)(nil)
+
+func (t *MedianTask) Type() TaskType { return TaskTypeMedian }
+
+func (t *MedianTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	values := make([]decimal.Decimal, 0, len(vars))
+	for _, v := range vars {
+		if v.Error != nil {
+			continue
+		}
+		d, err := toDecimal(v.Value)
+		if err != nil {
+			continue
+		}
+		values = append(values, d)
+	}
+	if len(values) == 0 {
+		return Result{Error: errors.New("median task: no values to aggregate")}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].LessThan(values[j]) })
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return Result{Value: values[mid]}
+	}
+	median := values[mid-1].Add(values[mid]).Div(decimal.NewFromInt(2))
+	return Result{Value: median}
+}
+
+func toDecimal(val interface{}) (decimal.Decimal, error) {
+	switch v := val.(type) {
+	case decimal.Decimal:
+		return v, nil
+	case string:
+		return decimal.NewFromString(v)
+	case float64:
+		// JSON numbers decode to float64.
+		return decimal.NewFromFloat(v), nil
+	default:
+		return decimal.NewFromString("")
+	}
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MultiplyTask scales its single dependency's numeric output by Times,
+// most commonly used to shift a decimal answer into on-chain integer
+// units.
+type MultiplyTask struct {
+	BaseTask
+
+	Times decimal.Decimal
+}
+
+var _ Task = (*MultiplyTask)(nil)
+
+func (t *MultiplyTask) Type() TaskType { return TaskTypeMultiply }
+
+func (t *MultiplyTask) Run(ctx context.Context, taskRunID uuid.UUID, vars []Result) Result {
+	if len(vars) == 0 || vars[0].Error != nil {
+		return Result{Error: fmt.Errorf("multiply task: no input to multiply")}
+	}
+	d, err := toDecimal(vars[0].Value)
+	if err != nil {
+		return Result{Error: err}
+	}
+	return Result{Value: d.Mul(t.Times)}
+}
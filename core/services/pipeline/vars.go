@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// substituteRunVars rewrites $(tasks.<dot_id>.status) and
+// $(tasks.<dot_id>.out) references in finally tasks' attributes, giving
+// them access to the main DAG's outcome. Only BridgeTask.RequestData is
+// templated today, since notifying an external system is the primary use
+// case for a finally block.
+func substituteRunVars(tasks []Task, run *Run, mainStatus RunStatus) {
+	for _, task := range tasks {
+		bt, ok := task.(*BridgeTask)
+		if !ok {
+			continue
+		}
+		bt.RequestData = substituteRunVarsInString(bt.RequestData, run, mainStatus)
+	}
+}
+
+func substituteRunVarsInString(s string, run *Run, mainStatus RunStatus) string {
+	for _, tr := range run.PipelineTaskRuns {
+		s = strings.ReplaceAll(s, fmt.Sprintf("$(tasks.%s.status)", tr.DotID), string(taskRunStatus(tr)))
+		if tr.Output != nil {
+			s = strings.ReplaceAll(s, fmt.Sprintf("$(tasks.%s.out)", tr.DotID), fmt.Sprintf("%v", tr.Output.Val))
+		}
+	}
+	s = strings.ReplaceAll(s, "$(jobRun.status)", string(mainStatus))
+	return s
+}
+
+func taskRunStatus(tr TaskRun) RunStatus {
+	switch {
+	case !tr.FinishedAt.Valid:
+		return RunStatusRunning
+	case tr.Error.Valid:
+		return RunStatusErrored
+	case tr.Skipped:
+		return RunStatusPartial
+	default:
+		return RunStatusCompleted
+	}
+}